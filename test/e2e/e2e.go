@@ -17,9 +17,12 @@ limitations under the License.
 package e2e
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/kubernetes-sigs/kubebuilder/test/e2e/framework"
+	"github.com/kubernetes-sigs/kubebuilder/test/e2e/framework/cluster"
 	"github.com/kubernetes-sigs/kubebuilder/test/e2e/framework/ginkgowrapper"
 
 	"github.com/golang/glog"
@@ -33,35 +36,95 @@ import (
 func RunE2ETests(t *testing.T) {
 	gomega.RegisterFailHandler(ginkgowrapper.Fail)
 	glog.Infof("Starting kubebuilder suite")
-	RunSpecs(t, "Kubebuilder e2e suite")
-}
-
-var _ = Describe("Kubebuilder workflow", func() {
-	By("init project")
-	framework.RunCommandOrDie(framework.KubebuilderCommand, "init", "--domain", "example.com")
-
-	By("creating resource definition")
-	framework.RunCommandOrDie(framework.KubebuilderCommand,
-		"create", "resource", "--group", "bar", "--version", "alpha1", "--kind", "Foo")
 
-	By("creating core-type resource controller")
-	framework.RunCommandOrDie(framework.KubebuilderCommand,
-		"create", "controller", "--group", "apps", "--version", "v1beta2", "--kind", "Deployment", "--core-type")
+	// Provision the cluster the suite targets (kind/minikube/existing,
+	// selected via --provisioner) so `go test ./test/e2e/...` can run on a
+	// fresh machine with no pre-existing cluster.
+	provisioner, err := cluster.New(framework.TestContext.Host, framework.TestContext.KubeConfig, framework.TestContext.KubeContext)
+	if err != nil {
+		t.Fatalf("error selecting cluster provisioner: %v", err)
+	}
+	ctx := context.Background()
+	cfg, err := provisioner.Setup(ctx)
+	if err != nil {
+		t.Fatalf("error provisioning cluster: %v", err)
+	}
+	if cfg != nil {
+		framework.TestContext.Host = cfg.Host
+	}
+	if kubeconfig := provisioner.KubeConfigPath(); kubeconfig != "" {
+		framework.TestContext.KubeConfig = kubeconfig
+	}
+	defer func() {
+		if err := provisioner.Teardown(ctx); err != nil {
+			glog.Errorf("error tearing down cluster: %v", err)
+		}
+	}()
 
-	By("building image")
-	imageName := "gcr.io/kubeships/controller-manager:" + framework.NowStamp()
-	framework.RunCommandOrDie(framework.DockerCommand,
-		"build", framework.TestContext.ProjectDir, "Dockerfile.controller", "-t", imageName)
-	defer framework.RunCommandOrDie(framework.DockerCommand, "rmi", "-f", imageName)
-
-	By("creating config")
-	framework.RunCommandOrDie(framework.KubebuilderCommand,
-		"create", "config", "--controller-image", "imageName", "--name", "kubebar")
-
-	By("installing controller-manager in cluster")
-	framework.RunCommandOrDie(framework.KubectlCommand, "apply", "-f", framework.TestContext.ProjectDir+"hack/install.yaml")
+	RunSpecs(t, "Kubebuilder e2e suite")
 
-	By("creating resource object")
-	framework.RunCommandOrDie(framework.KubectlCommand, "create", "-f", framework.TestContext.ProjectDir+"hack/sample/foo.yaml")
+	if t.Failed() && framework.TestContext.ArtifactDir != "" {
+		if err := provisioner.CollectLogs(framework.TestContext.ArtifactDir); err != nil {
+			glog.Errorf("error collecting cluster logs: %v", err)
+		}
+	}
+}
 
+var _ = Describe("Kubebuilder workflow", func() {
+	// The whole scenario lives in a single It so CurrentGinkgoTestDescription
+	// (read in the deferred ws.Close below) reflects a real running spec;
+	// called from the Describe body itself it's always the zero value, which
+	// would permanently disable the archive-on-failure path in ws.Close.
+	It("should scaffold, build, and deploy a sample controller", func() {
+		// Each spec gets its own GOPATH-shaped workspace instead of mutating
+		// the shared TestContext.ProjectDir, so this Describe can later be
+		// expanded into many --domain/group/version/kind scenarios run in
+		// parallel via `ginkgo -p` without them contaminating each other's
+		// state.
+		importPath := "github.com/example-inc/app-operator"
+		ws := framework.NewWorkspace(GinkgoT())
+		defer func() {
+			ws.Close(CurrentGinkgoTestDescription().Failed)
+		}()
+
+		By("init project")
+		ws.NewCommand(importPath, framework.KubebuilderCommand, "init", "--domain", "example.com").ExecOrDie()
+
+		By("creating resource definition")
+		ws.NewCommand(importPath, framework.KubebuilderCommand,
+			"create", "resource", "--group", "bar", "--version", "alpha1", "--kind", "Foo").ExecOrDie()
+
+		By("creating core-type resource controller")
+		ws.NewCommand(importPath, framework.KubebuilderCommand,
+			"create", "controller", "--group", "apps", "--version", "v1beta2", "--kind", "Deployment", "--core-type").ExecOrDie()
+
+		By("building image")
+		imageName := "gcr.io/kubeships/controller-manager:" + framework.NowStamp()
+		framework.RunCommandOrDie(framework.DockerCommand,
+			"build", ws.ProjectDir(importPath), "Dockerfile.controller", "-t", imageName)
+		defer framework.RunCommandOrDie(framework.DockerCommand, "rmi", "-f", imageName)
+
+		// Make the freshly built image reachable from inside the cluster: a
+		// plain `docker build` only populates the local docker daemon, which
+		// kind/minikube's own daemon can't see without this step.
+		provisioner, err := cluster.New(framework.TestContext.Host, framework.TestContext.KubeConfig, framework.TestContext.KubeContext)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		gomega.Expect(provisioner.LoadImage(imageName)).NotTo(gomega.HaveOccurred())
+
+		By("creating config")
+		ws.NewCommand(importPath, framework.KubebuilderCommand,
+			"create", "config", "--controller-image", imageName, "--name", "kubebar").ExecOrDie()
+
+		By("installing controller-manager in cluster")
+		framework.NewCommand(framework.KubectlCommand, "apply", "-f", ws.ProjectDir(importPath)+"/hack/install.yaml").
+			WithRetry(5, 3*time.Second, framework.IsTransientAPIServerError).
+			ExecOrDie()
+
+		By("waiting for controller-manager to be ready")
+		gomega.Expect(framework.WaitForDeploymentReady("kubebar-system", "kubebar-controller-manager", 2*time.Minute)).
+			NotTo(gomega.HaveOccurred())
+
+		By("creating resource object")
+		framework.RunCommandOrDie(framework.KubectlCommand, "create", "-f", ws.ProjectDir(importPath)+"/hack/sample/foo.yaml")
+	})
 })
\ No newline at end of file
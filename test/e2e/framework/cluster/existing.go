@@ -0,0 +1,82 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// ExistingProvisioner targets a cluster that's already reachable via the
+// host/kubeconfig/context it's given. Setup creates nothing and only
+// resolves a rest.Config from them, Teardown and CollectLogs are no-ops, and
+// LoadImage expects the image to already be reachable by the cluster (e.g.
+// pushed to a registry). This matches the framework's original behavior,
+// from before any provisioning existed.
+type ExistingProvisioner struct {
+	Host        string
+	KubeConfig  string
+	KubeContext string
+}
+
+func NewExistingProvisioner(host, kubeconfig, kubecontext string) *ExistingProvisioner {
+	return &ExistingProvisioner{Host: host, KubeConfig: kubeconfig, KubeContext: kubecontext}
+}
+
+// Setup is a true no-op when the caller hasn't told it about a kubeconfig or
+// host to use: TestContext.Host/KubeConfig are normally empty for a caller
+// relying on TestContext.CertDir's cert-based kubectl invocation instead, and
+// resolving a client config in that case would wrongly turn "no discoverable
+// kubeconfig" into a suite-fatal error before a single spec runs.
+func (p *ExistingProvisioner) Setup(ctx context.Context) (*rest.Config, error) {
+	if p.Host == "" && p.KubeConfig == "" && p.KubeContext == "" {
+		return nil, nil
+	}
+
+	overrides := &clientcmd.ConfigOverrides{}
+	if p.Host != "" {
+		overrides.ClusterInfo.Server = p.Host
+	}
+	if p.KubeContext != "" {
+		overrides.CurrentContext = p.KubeContext
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if p.KubeConfig != "" {
+		loadingRules.ExplicitPath = p.KubeConfig
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+}
+
+// KubeConfigPath returns the kubeconfig path the caller already gave us;
+// ExistingProvisioner never generates one of its own.
+func (p *ExistingProvisioner) KubeConfigPath() string {
+	return p.KubeConfig
+}
+
+func (p *ExistingProvisioner) Teardown(ctx context.Context) error {
+	return nil
+}
+
+func (p *ExistingProvisioner) LoadImage(ref string) error {
+	return nil
+}
+
+func (p *ExistingProvisioner) CollectLogs(dir string) error {
+	return nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const kindConfigYAML = `kind: Cluster
+apiVersion: kind.sigs.k8s.io/v1alpha3
+nodes:
+- role: control-plane
+`
+
+// KindProvisioner stands up a disposable cluster with `kind create cluster`
+// and tears it down with `kind delete cluster`, so the e2e suite can run on
+// a fresh machine with no pre-existing cluster. Images built during the
+// suite are made available to the cluster with `kind load docker-image`
+// rather than pushed to a registry.
+type KindProvisioner struct {
+	ClusterName string
+
+	kubeconfig string
+}
+
+func NewKindProvisioner() *KindProvisioner {
+	return &KindProvisioner{ClusterName: "kubebuilder-e2e"}
+}
+
+func (p *KindProvisioner) Setup(ctx context.Context) (*rest.Config, error) {
+	configFile, err := ioutil.TempFile("", "kind-config-")
+	if err != nil {
+		return nil, fmt.Errorf("error writing kind config: %v", err)
+	}
+	if _, err := configFile.WriteString(kindConfigYAML); err != nil {
+		return nil, fmt.Errorf("error writing kind config: %v", err)
+	}
+	configFile.Close()
+
+	createCmd := exec.CommandContext(ctx, "kind", "create", "cluster", "--name", p.ClusterName, "--config", configFile.Name())
+	if out, err := createCmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("error creating kind cluster %q: %v\n%s", p.ClusterName, err, out)
+	}
+
+	kubeconfigCmd := exec.CommandContext(ctx, "kind", "get", "kubeconfig-path", "--name", p.ClusterName)
+	out, err := kubeconfigCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error locating kind kubeconfig for %q: %v", p.ClusterName, err)
+	}
+	p.kubeconfig = strings.TrimSpace(string(out))
+
+	return clientcmd.BuildConfigFromFlags("", p.kubeconfig)
+}
+
+// KubeConfigPath returns the kubeconfig `kind get kubeconfig-path` wrote
+// during Setup.
+func (p *KindProvisioner) KubeConfigPath() string {
+	return p.kubeconfig
+}
+
+func (p *KindProvisioner) Teardown(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, "kind", "delete", "cluster", "--name", p.ClusterName)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error deleting kind cluster %q: %v\n%s", p.ClusterName, err, out)
+	}
+	return nil
+}
+
+func (p *KindProvisioner) LoadImage(ref string) error {
+	cmd := exec.Command("kind", "load", "docker-image", ref, "--name", p.ClusterName)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error loading image %q into kind cluster %q: %v\n%s", ref, p.ClusterName, err, out)
+	}
+	return nil
+}
+
+func (p *KindProvisioner) CollectLogs(dir string) error {
+	cmd := exec.Command("kind", "export", "logs", dir, "--name", p.ClusterName)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error exporting kind logs for %q: %v\n%s", p.ClusterName, err, out)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// MinikubeProvisioner stands up a local cluster with `minikube start` and
+// tears it down with `minikube delete`.
+type MinikubeProvisioner struct {
+	Profile string
+
+	kubeconfig string
+}
+
+func NewMinikubeProvisioner() *MinikubeProvisioner {
+	return &MinikubeProvisioner{Profile: "minikube"}
+}
+
+func (p *MinikubeProvisioner) Setup(ctx context.Context) (*rest.Config, error) {
+	cmd := exec.CommandContext(ctx, "minikube", "start", "-p", p.Profile)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("error starting minikube profile %q: %v\n%s", p.Profile, err, out)
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	p.kubeconfig = loadingRules.GetDefaultFilename()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: p.Profile}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+}
+
+// KubeConfigPath returns the kubeconfig minikube wrote to, resolved the
+// same way Setup's clientcmd loading rules resolved it ($KUBECONFIG or
+// ~/.kube/config).
+func (p *MinikubeProvisioner) KubeConfigPath() string {
+	return p.kubeconfig
+}
+
+func (p *MinikubeProvisioner) Teardown(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, "minikube", "delete", "-p", p.Profile)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error deleting minikube profile %q: %v\n%s", p.Profile, err, out)
+	}
+	return nil
+}
+
+// LoadImage makes a locally built image available to minikube's VM/container
+// via `minikube cache add`, instead of pushing it to a registry.
+func (p *MinikubeProvisioner) LoadImage(ref string) error {
+	cmd := exec.Command("minikube", "cache", "add", "-p", p.Profile, ref)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error loading image %q into minikube profile %q: %v\n%s", ref, p.Profile, err, out)
+	}
+	return nil
+}
+
+func (p *MinikubeProvisioner) CollectLogs(dir string) error {
+	cmd := exec.Command("minikube", "logs", "-p", p.Profile)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error collecting minikube logs for profile %q: %v", p.Profile, err)
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "minikube.log"), out, 0644)
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cluster provides pluggable provisioning of the Kubernetes cluster
+// an e2e run targets, so the suite can stand up its own disposable cluster
+// instead of assuming one is already reachable.
+package cluster
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"k8s.io/client-go/rest"
+)
+
+// Names accepted by the --provisioner flag.
+const (
+	Kind     = "kind"
+	Minikube = "minikube"
+	Existing = "existing"
+)
+
+var provisionerName = flag.String("provisioner", Existing,
+	"cluster provisioner to use for the e2e suite: kind, minikube, or existing")
+
+// Provisioner stands up (and tears down) the cluster an e2e run targets.
+type Provisioner interface {
+	// Setup provisions the cluster, a no-op for ExistingProvisioner, and
+	// returns a rest.Config for talking to it. ExistingProvisioner returns
+	// a nil rest.Config when it was given no host, kubeconfig or context.
+	Setup(ctx context.Context) (*rest.Config, error)
+	// KubeConfigPath returns the path to a kubeconfig file authorized
+	// against the cluster Setup provisioned, or "" if Setup didn't
+	// generate one (e.g. ExistingProvisioner, which expects the caller's
+	// own Host/KubeConfig/KubeContext to already be usable). Valid only
+	// after Setup returns successfully.
+	KubeConfigPath() string
+	// Teardown tears the cluster down; a no-op for ExistingProvisioner.
+	Teardown(ctx context.Context) error
+	// LoadImage makes a locally built image available to the cluster
+	// without pushing it to a registry; a no-op for ExistingProvisioner.
+	LoadImage(ref string) error
+	// CollectLogs dumps kubelet/controller-manager/pod logs into dir for
+	// post-mortem debugging when the suite fails. ExistingProvisioner
+	// collects nothing and returns nil.
+	CollectLogs(dir string) error
+}
+
+// New returns the Provisioner selected by the --provisioner flag. host,
+// kubeconfig and kubecontext are only used by ExistingProvisioner, to target
+// the cluster the caller already has configured.
+func New(host, kubeconfig, kubecontext string) (Provisioner, error) {
+	switch *provisionerName {
+	case Kind:
+		return NewKindProvisioner(), nil
+	case Minikube:
+		return NewMinikubeProvisioner(), nil
+	case Existing, "":
+		return NewExistingProvisioner(host, kubeconfig, kubecontext), nil
+	default:
+		return nil, fmt.Errorf("unknown --provisioner %q", *provisionerName)
+	}
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// RunResult carries everything a CommandRunner captured about one command
+// invocation, regardless of where it actually executed.
+type RunResult struct {
+	// Cmd is the command as it was requested. For runners that don't
+	// execute it directly (SSHRunner, KindRunner), it still reflects the
+	// original path/args passed in, not the wrapper command used to get
+	// there.
+	Cmd      *exec.Cmd
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Duration time.Duration
+}
+
+// CommandRunner abstracts over where a command actually executes, so the
+// same *exec.Cmd built by KubectlCmd/KubebuilderCmd/NewCommand can run
+// locally, on a remote host over SSH, or inside a kind node container
+// without the callers in e2e specs having to change.
+//
+// cmd.Args, including cmd.Args[0] (TestContext.KubectlPath/KubebuilderPath,
+// a path on the local test-runner host), is passed through verbatim to
+// SSHRunner/KindRunner. Neither translates it into wherever the binary
+// actually lives on the remote host or inside the kind node, so
+// WithRunner(SSHRunner{...})/WithRunner(KindRunner{...}) only works when the
+// configured tool paths resolve to a real binary on the target side too
+// (e.g. by putting matching binaries on $PATH there, or pointing
+// TestContext.KubectlPath/KubebuilderPath at a path that exists on both).
+type CommandRunner interface {
+	// RunCmd runs cmd to completion and returns its captured output.
+	RunCmd(cmd *exec.Cmd) (RunResult, error)
+	// RunCmdWithStdin behaves like RunCmd, but feeds stdin to the command.
+	RunCmdWithStdin(cmd *exec.Cmd, stdin io.Reader) (RunResult, error)
+}
+
+// LocalRunner runs commands as a subprocess of the test binary. It is the
+// default CommandRunner and matches the framework's original behavior.
+type LocalRunner struct{}
+
+func NewLocalRunner() *LocalRunner {
+	return &LocalRunner{}
+}
+
+func (r *LocalRunner) RunCmd(cmd *exec.Cmd) (RunResult, error) {
+	return r.run(cmd)
+}
+
+func (r *LocalRunner) RunCmdWithStdin(cmd *exec.Cmd, stdin io.Reader) (RunResult, error) {
+	cmd.Stdin = stdin
+	return r.run(cmd)
+}
+
+func (r *LocalRunner) run(cmd *exec.Cmd) (RunResult, error) {
+	var capturedStdout, capturedStderr bytes.Buffer
+	cmd.Stdout = teeOrSelf(cmd.Stdout, &capturedStdout)
+	cmd.Stderr = teeOrSelf(cmd.Stderr, &capturedStderr)
+
+	start := time.Now()
+	err := cmd.Run()
+	result := RunResult{
+		Cmd:      cmd,
+		Stdout:   capturedStdout.String(),
+		Stderr:   capturedStderr.String(),
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		result.ExitCode = exitCodeOf(err)
+		return result, err
+	}
+	return result, nil
+}
+
+// teeOrSelf returns a writer that fans out to both existing and capture,
+// or just capture when existing is nil.
+func teeOrSelf(existing io.Writer, capture io.Writer) io.Writer {
+	if existing == nil {
+		return capture
+	}
+	return io.MultiWriter(existing, capture)
+}
+
+func exitCodeOf(err error) int {
+	if ee, ok := err.(*exec.ExitError); ok {
+		return int(ee.Sys().(syscall.WaitStatus).ExitStatus())
+	}
+	return 127
+}
+
+// SSHRunner executes commands on a remote host over SSH, for testing the
+// generated scaffold against a build environment where kubebuilder/docker
+// live on a different machine than the one running the test binary.
+type SSHRunner struct {
+	Host   string
+	User   string
+	Signer ssh.Signer
+}
+
+func NewSSHRunner(host, user string, signer ssh.Signer) *SSHRunner {
+	return &SSHRunner{Host: host, User: user, Signer: signer}
+}
+
+func (r *SSHRunner) RunCmd(cmd *exec.Cmd) (RunResult, error) {
+	return r.RunCmdWithStdin(cmd, nil)
+}
+
+func (r *SSHRunner) RunCmdWithStdin(cmd *exec.Cmd, stdin io.Reader) (RunResult, error) {
+	client, err := ssh.Dial("tcp", r.Host, &ssh.ClientConfig{
+		User:            r.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(r.Signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		return RunResult{Cmd: cmd}, fmt.Errorf("error dialing %s: %v", r.Host, err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return RunResult{Cmd: cmd}, fmt.Errorf("error creating ssh session to %s: %v", r.Host, err)
+	}
+	defer session.Close()
+
+	var capturedStdout, capturedStderr bytes.Buffer
+	session.Stdout = teeOrSelf(cmd.Stdout, &capturedStdout)
+	session.Stderr = teeOrSelf(cmd.Stderr, &capturedStderr)
+	if stdin != nil {
+		session.Stdin = stdin
+	}
+
+	start := time.Now()
+	err = session.Run(remoteCommand(cmd))
+	result := RunResult{
+		Cmd:      cmd,
+		Stdout:   capturedStdout.String(),
+		Stderr:   capturedStderr.String(),
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		if ee, ok := err.(*ssh.ExitError); ok {
+			result.ExitCode = ee.ExitStatus()
+		} else {
+			result.ExitCode = 127
+		}
+		return result, fmt.Errorf("error running %v over ssh to %s: %v", cmd.Args, r.Host, err)
+	}
+	return result, nil
+}
+
+// shellJoin renders args as a single string safe to hand to a remote shell,
+// quoting each argument individually.
+func shellJoin(args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, a := range args {
+		quoted = append(quoted, shellQuote(a))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote single-quotes s for safe inclusion in a remote shell command
+// line, escaping any embedded single quotes.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// remoteCommand renders cmd as a single command line for the remote shell,
+// translating cmd.Dir into a leading `cd` and cmd.Env into inline `export`s
+// so SSHRunner applies cmd.Dir/cmd.Env on the remote side. Unlike
+// LocalRunner, cmd.Env is added on top of the remote shell's environment
+// rather than replacing it.
+func remoteCommand(cmd *exec.Cmd) string {
+	var parts []string
+	if cmd.Dir != "" {
+		parts = append(parts, "cd "+shellQuote(cmd.Dir))
+	}
+	for _, kv := range cmd.Env {
+		parts = append(parts, "export "+shellQuote(kv))
+	}
+	parts = append(parts, shellJoin(cmd.Args))
+	return strings.Join(parts, " && ")
+}
+
+// KindRunner execs commands inside a running kind node container via
+// `docker exec`, for testing the generated scaffold from inside the
+// cluster's own nodes instead of the host running the test binary.
+type KindRunner struct {
+	NodeName string
+}
+
+func NewKindRunner(nodeName string) *KindRunner {
+	return &KindRunner{NodeName: nodeName}
+}
+
+func (r *KindRunner) RunCmd(cmd *exec.Cmd) (RunResult, error) {
+	return r.RunCmdWithStdin(cmd, nil)
+}
+
+func (r *KindRunner) RunCmdWithStdin(cmd *exec.Cmd, stdin io.Reader) (RunResult, error) {
+	dockerArgs := []string{"exec"}
+	if stdin != nil {
+		dockerArgs = append(dockerArgs, "-i")
+	}
+	if cmd.Dir != "" {
+		dockerArgs = append(dockerArgs, "-w", cmd.Dir)
+	}
+	for _, kv := range cmd.Env {
+		dockerArgs = append(dockerArgs, "-e", kv)
+	}
+	dockerArgs = append(dockerArgs, r.NodeName)
+	dockerArgs = append(dockerArgs, cmd.Args...)
+
+	// dockerCmd is the local `docker exec` invocation: Dir/Env above target
+	// the containerized process via -w/-e, not this local docker client.
+	dockerCmd := exec.Command("docker", dockerArgs...)
+	dockerCmd.Stdout = cmd.Stdout
+	dockerCmd.Stderr = cmd.Stderr
+
+	local := NewLocalRunner()
+	var result RunResult
+	var err error
+	if stdin != nil {
+		result, err = local.RunCmdWithStdin(dockerCmd, stdin)
+	} else {
+		result, err = local.RunCmd(dockerCmd)
+	}
+	result.Cmd = cmd
+	return result, err
+}
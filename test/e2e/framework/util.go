@@ -18,14 +18,16 @@ package framework
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/url"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"syscall"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -79,10 +81,13 @@ func KubectlCmd(args ...string) *exec.Cmd {
 }
 
 // KubebuilderCmd runs the kubebuilder executable through the wrapper script.
+// Callers that need an isolated project directory, e.g. to run in parallel,
+// should use a ProjectWorkspace instead of relying on this default.
 func KubebuilderCmd(args ...string) *exec.Cmd {
 	cmd := exec.Command(TestContext.KubebuilderPath, args...)
-	// Set projectDir as the work path of kubebuilder
-	cmd.Path = TestContext.ProjectDir
+	// Run kubebuilder in the shared project dir unless a ProjectWorkspace
+	// overrides cmd.Dir.
+	cmd.Dir = TestContext.ProjectDir
 
 	//caller will invoke this and wait on it.
 	return cmd
@@ -92,43 +97,158 @@ func KubebuilderCmd(args ...string) *exec.Cmd {
 // Add more functions to customize the builder as needed.
 type CommandBuilder struct {
 	cmdType string
+	args    []string
 	cmd     *exec.Cmd
 	timeout <-chan time.Time
+
+	env []string
+	dir string
+
+	// stdinData and hasStdin back WithStdinData/WithStdinReader. The data is
+	// captured up front (rather than keeping the caller's io.Reader) so
+	// buildCmd can wrap a fresh bytes.Reader on every WithRetry attempt
+	// instead of handing a single, already-drained reader to attempt 2+.
+	stdinData []byte
+	hasStdin  bool
+
+	// stdoutWriter and stderrWriter, when set, receive a live copy of the
+	// command's output as it runs, in addition to the buffer used to build
+	// the string returned by Exec/ExecStream.
+	stdoutWriter io.Writer
+	stderrWriter io.Writer
+
+	// runner executes the built *exec.Cmd; it defaults to a LocalRunner but
+	// can be swapped out with WithRunner to run against a remote host or a
+	// kind node instead.
+	runner CommandRunner
+
+	// retryAttempts, retryBackoff and retryable configure Exec to retry a
+	// failed command; see WithRetry.
+	retryAttempts int
+	retryBackoff  time.Duration
+	retryable     func(err error, stderr string) bool
 }
 
+// defaultRunner is used by CommandBuilders that never call WithRunner,
+// preserving the framework's original local-subprocess behavior.
+var defaultRunner CommandRunner = NewLocalRunner()
+
 func NewCommand(cmdType string, args ...string) *CommandBuilder {
 	b := new(CommandBuilder)
 	b.cmdType = cmdType
-	switch cmdType {
+	b.args = args
+	b.cmd = b.buildCmd()
+	return b
+}
+
+// buildCmd constructs a fresh *exec.Cmd from the builder's recipe (cmdType,
+// args, env, dir, stdin). exec.Cmd instances can only be run once, so
+// WithRetry needs a new one for every attempt rather than reusing b.cmd.
+func (b CommandBuilder) buildCmd() *exec.Cmd {
+	var cmd *exec.Cmd
+	switch b.cmdType {
 	case KubectlCommand:
-		b.cmd = KubectlCmd(args...)
+		cmd = KubectlCmd(b.args...)
 	case KubebuilderCommand:
-		b.cmd = KubebuilderCmd(args...)
+		cmd = KubebuilderCmd(b.args...)
 	case DockerCommand:
-		b.cmd = exec.Command("docker", args...)
+		cmd = exec.Command("docker", b.args...)
 	default:
-		Failf("Invalid command type: %s", cmdType)
+		Failf("Invalid command type: %s", b.cmdType)
 	}
-	return b
+	if b.env != nil {
+		cmd.Env = b.env
+	}
+	if b.dir != "" {
+		cmd.Dir = b.dir
+	}
+	if b.hasStdin {
+		cmd.Stdin = bytes.NewReader(b.stdinData)
+	}
+	return cmd
 }
 
 func (b *CommandBuilder) WithEnv(env []string) *CommandBuilder {
+	b.env = env
 	b.cmd.Env = env
 	return b
 }
 
+// WithDir overrides the directory the command runs in, e.g. to bind it to a
+// ProjectWorkspace instead of the default TestContext.ProjectDir.
+func (b *CommandBuilder) WithDir(dir string) *CommandBuilder {
+	b.dir = dir
+	b.cmd.Dir = dir
+	return b
+}
+
 func (b *CommandBuilder) WithTimeout(t <-chan time.Time) *CommandBuilder {
 	b.timeout = t
 	return b
 }
 
+// WithRetry makes Exec retry the command up to attempts times, sleeping
+// backoff between tries, as long as retryable reports the failure as
+// transient. This lets callers handle transient apiserver errors uniformly
+// across all three command types, rather than relying on the one-off retry
+// isTimeout does for `kubectl version`.
+func (b *CommandBuilder) WithRetry(attempts int, backoff time.Duration, retryable func(err error, stderr string) bool) *CommandBuilder {
+	b.retryAttempts = attempts
+	b.retryBackoff = backoff
+	b.retryable = retryable
+	return b
+}
+
+// WithRunner makes the builder execute its command through r instead of the
+// default LocalRunner, e.g. to run against a remote host via SSHRunner or
+// inside a kind node via KindRunner. See CommandRunner's doc comment: r gets
+// the same cmd.Args[0] (a local tool path) the builder would've exec'd
+// locally, so SSHRunner/KindRunner only work when that path also resolves
+// on the remote/in-container side.
+func (b *CommandBuilder) WithRunner(r CommandRunner) *CommandBuilder {
+	b.runner = r
+	return b
+}
+
+func (b CommandBuilder) runnerOrDefault() CommandRunner {
+	if b.runner != nil {
+		return b.runner
+	}
+	return defaultRunner
+}
+
 func (b CommandBuilder) WithStdinData(data string) *CommandBuilder {
-	b.cmd.Stdin = strings.NewReader(data)
+	b.stdinData = []byte(data)
+	b.hasStdin = true
+	b.cmd.Stdin = bytes.NewReader(b.stdinData)
 	return &b
 }
 
+// WithStdinReader reads reader to completion up front and replays it from
+// memory on every attempt, so it composes safely with WithRetry the same
+// way WithStdinData does.
 func (b CommandBuilder) WithStdinReader(reader io.Reader) *CommandBuilder {
-	b.cmd.Stdin = reader
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		Failf("error reading stdin for command %s: %v", b.cmdType, err)
+	}
+	b.stdinData = data
+	b.hasStdin = true
+	b.cmd.Stdin = bytes.NewReader(b.stdinData)
+	return &b
+}
+
+// WithStdoutWriter tees the command's stdout to w as the command runs, in
+// addition to the buffer captured and returned by Exec/ExecStream.
+func (b CommandBuilder) WithStdoutWriter(w io.Writer) *CommandBuilder {
+	b.stdoutWriter = w
+	return &b
+}
+
+// WithStderrWriter tees the command's stderr to w as the command runs, in
+// addition to the buffer captured and returned by Exec/ExecStream.
+func (b CommandBuilder) WithStderrWriter(w io.Writer) *CommandBuilder {
+	b.stderrWriter = w
 	return &b
 }
 
@@ -166,35 +286,158 @@ func isTimeout(err error) bool {
 }
 
 func (b CommandBuilder) Exec() (string, error) {
-	var stdout, stderr bytes.Buffer
-	cmd := b.cmd
-	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	attempts := b.retryAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
 
-	Logf("Running '%s %s'", cmd.Path, strings.Join(cmd.Args[1:], " ")) // skip arg[0] as it is printed separately
-	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("error starting %v:\nCommand stdout:\n%v\nstderr:\n%v\nerror:\n%v\n", cmd, cmd.Stdout, cmd.Stderr, err)
+	var result RunResult
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			Logf("retrying '%s %s' (attempt %d/%d) after %v", b.cmdType, strings.Join(b.args, " "), attempt, attempts, b.retryBackoff)
+			time.Sleep(b.retryBackoff)
+		}
+		result, err = b.execOnce()
+		if err == nil {
+			return result.Stdout, nil
+		}
+		if b.retryable == nil || !b.retryable(err, result.Stderr) {
+			break
+		}
 	}
+	return "", err
+}
+
+// execOnce runs the command a single time and returns its captured output.
+func (b CommandBuilder) execOnce() (RunResult, error) {
+	cmd := b.buildCmd()
+
+	logFile, err := b.openLogFile()
+	if err != nil {
+		return RunResult{}, err
+	}
+	if logFile != nil {
+		defer logFile.Close()
+	}
+	cmd.Stdout, cmd.Stderr = b.teeWriters(logFile)
+
+	Logf("Running '%s %s'", cmd.Path, strings.Join(cmd.Args[1:], " ")) // skip arg[0] as it is printed separately
+
+	resultCh := make(chan RunResult, 1)
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- cmd.Wait()
+		result, err := b.run(cmd)
+		resultCh <- result
+		errCh <- err
 	}()
 	select {
 	case err := <-errCh:
+		result := <-resultCh
 		if err != nil {
-			var rc int = 127
-			if ee, ok := err.(*exec.ExitError); ok {
-				rc = int(ee.Sys().(syscall.WaitStatus).ExitStatus())
-				Logf("rc: %d", rc)
-			}
-			return "", fmt.Errorf("error running %v:\nCommand stdout:\n%v\nstderr:\n%v\nerror:\n%v\ncode: %d", cmd, cmd.Stdout, cmd.Stderr, err, rc)
+			Logf("rc: %d", result.ExitCode)
+			return result, fmt.Errorf("error running %v:\nCommand stdout:\n%v\nstderr:\n%v\nerror:\n%v\ncode: %d", cmd, result.Stdout, result.Stderr, err, result.ExitCode)
 		}
+		Logf("stderr: %q", result.Stderr)
+		Logf("stdout: %q", result.Stdout)
+		return result, nil
 	case <-b.timeout:
-		b.cmd.Process.Kill()
-		return "", fmt.Errorf("timed out waiting for command %v:\nCommand stdout:\n%v\nstderr:\n%v\n", cmd, cmd.Stdout, cmd.Stderr)
+		cmd.Process.Kill()
+		return RunResult{}, fmt.Errorf("timed out waiting for command %v", cmd)
 	}
-	Logf("stderr: %q", stderr.String())
-	Logf("stdout: %q", stdout.String())
-	return stdout.String(), nil
+}
+
+// run dispatches cmd to the configured CommandRunner, routing through
+// RunCmdWithStdin when the caller set up stdin via WithStdinData/WithStdinReader.
+func (b CommandBuilder) run(cmd *exec.Cmd) (RunResult, error) {
+	runner := b.runnerOrDefault()
+	if cmd.Stdin != nil {
+		return runner.RunCmdWithStdin(cmd, cmd.Stdin)
+	}
+	return runner.RunCmd(cmd)
+}
+
+// ExecStream behaves like Exec, streaming stdout/stderr to any writers
+// configured via WithStdoutWriter/WithStderrWriter as the command runs, but
+// it runs the command once (WithRetry is not applied) and uses
+// exec.CommandContext for cancellation, so ctx being done kills the
+// underlying process directly rather than racing a timeout channel against
+// Process.Kill().
+func (b CommandBuilder) ExecStream(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, b.cmd.Path, b.cmd.Args[1:]...)
+	cmd.Env = b.cmd.Env
+	cmd.Dir = b.cmd.Dir
+	cmd.Stdin = b.cmd.Stdin
+
+	logFile, err := b.openLogFile()
+	if err != nil {
+		return "", err
+	}
+	if logFile != nil {
+		defer logFile.Close()
+	}
+	cmd.Stdout, cmd.Stderr = b.teeWriters(logFile)
+
+	Logf("Running '%s %s'", cmd.Path, strings.Join(cmd.Args[1:], " "))
+	result, err := b.run(cmd)
+	if err != nil {
+		if ctx.Err() != nil {
+			return result.Stdout, fmt.Errorf("command %v canceled:\nCommand stdout:\n%v\nstderr:\n%v\nerror:\n%v\n", cmd, result.Stdout, result.Stderr, ctx.Err())
+		}
+		Logf("rc: %d", result.ExitCode)
+		return result.Stdout, fmt.Errorf("error running %v:\nCommand stdout:\n%v\nstderr:\n%v\nerror:\n%v\ncode: %d", cmd, result.Stdout, result.Stderr, err, result.ExitCode)
+	}
+	Logf("stderr: %q", result.Stderr)
+	Logf("stdout: %q", result.Stdout)
+	return result.Stdout, nil
+}
+
+// teeWriters builds the extra stdout/stderr writers for a command
+// invocation from the caller's WithStdoutWriter/WithStderrWriter and the
+// per-command log file (if any); the CommandRunner is responsible for also
+// capturing output into the string it returns. Returns nil when there is
+// nothing extra to tee to.
+func (b CommandBuilder) teeWriters(logFile *os.File) (io.Writer, io.Writer) {
+	var outWriters, errWriters []io.Writer
+	if b.stdoutWriter != nil {
+		outWriters = append(outWriters, b.stdoutWriter)
+	}
+	if b.stderrWriter != nil {
+		errWriters = append(errWriters, b.stderrWriter)
+	}
+	if logFile != nil {
+		outWriters = append(outWriters, logFile)
+		errWriters = append(errWriters, logFile)
+	}
+	return multiOrNil(outWriters), multiOrNil(errWriters)
+}
+
+func multiOrNil(ws []io.Writer) io.Writer {
+	switch len(ws) {
+	case 0:
+		return nil
+	case 1:
+		return ws[0]
+	default:
+		return io.MultiWriter(ws...)
+	}
+}
+
+// openLogFile creates the per-invocation combined output log for this
+// command under TestContext.ArtifactDir, named after the command type and a
+// timestamp, so a run's output can still be triaged from CI/Prow artifacts
+// after the fact. It returns a nil file, with no error, when no artifact
+// directory is configured.
+func (b CommandBuilder) openLogFile() (*os.File, error) {
+	if TestContext.ArtifactDir == "" {
+		return nil, nil
+	}
+	name := fmt.Sprintf("%s-%s.log", b.cmdType, fileStamp())
+	f, err := os.Create(filepath.Join(TestContext.ArtifactDir, name))
+	if err != nil {
+		return nil, fmt.Errorf("error creating command log file: %v", err)
+	}
+	return f, nil
 }
 
 // RunCommandOrDie is a convenience wrapper over underlying command
@@ -216,6 +459,12 @@ func NowStamp() string {
 	return time.Now().Format(time.StampMilli)
 }
 
+// fileStamp returns a timestamp safe for use in a file name, unlike NowStamp
+// which is formatted for log readability.
+func fileStamp() string {
+	return time.Now().Format("20060102-150405.000")
+}
+
 func log(level string, format string, args ...interface{}) {
 	fmt.Fprintf(GinkgoWriter, NowStamp()+": "+level+": "+format+"\n", args...)
 }
@@ -0,0 +1,125 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// pollInterval is used by the WaitFor* helpers between polls of the
+// apiserver.
+const pollInterval = 2 * time.Second
+
+// IsTransientAPIServerError reports whether err/stderr look like a
+// transient failure talking to the apiserver, as opposed to e.g. a bad
+// manifest, and so is a reasonable retryable func to pass to WithRetry.
+func IsTransientAPIServerError(err error, stderr string) bool {
+	if isTimeout(err) {
+		return true
+	}
+	for _, s := range []string{
+		"connection refused",
+		"EOF",
+		"TLS handshake timeout",
+		"the server is currently unable to handle the request",
+	} {
+		if strings.Contains(stderr, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Eventually calls fn every interval until it returns nil or timeout
+// elapses. On timeout it returns a new error that includes the timeout and
+// the text of the last error fn produced.
+func Eventually(fn func() error, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %v: %v", timeout, err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// deploymentStatus is the subset of a Deployment's status this framework
+// needs, parsed out of `kubectl get -o json`.
+type deploymentStatus struct {
+	Status struct {
+		Replicas      int `json:"replicas"`
+		ReadyReplicas int `json:"readyReplicas"`
+	} `json:"status"`
+}
+
+// WaitForDeploymentReady polls the named Deployment until all of its
+// replicas are ready, or timeout elapses.
+func WaitForDeploymentReady(namespace, name string, timeout time.Duration) error {
+	return Eventually(func() error {
+		out, err := RunCommand(KubectlCommand, "get", "deployment", name, "-n", namespace, "-o", "json")
+		if err != nil {
+			return err
+		}
+		var d deploymentStatus
+		if err := json.Unmarshal([]byte(out), &d); err != nil {
+			return fmt.Errorf("error parsing deployment %s/%s: %v", namespace, name, err)
+		}
+		if d.Status.Replicas == 0 || d.Status.ReadyReplicas < d.Status.Replicas {
+			return fmt.Errorf("deployment %s/%s not ready: %d/%d replicas ready", namespace, name, d.Status.ReadyReplicas, d.Status.Replicas)
+		}
+		return nil
+	}, timeout, pollInterval)
+}
+
+// crdStatus is the subset of a CustomResourceDefinition's status this
+// framework needs, parsed out of `kubectl get -o json`.
+type crdStatus struct {
+	Status struct {
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// WaitForCRDEstablished polls the named CustomResourceDefinition until its
+// Established condition is True, or timeout elapses.
+func WaitForCRDEstablished(name string, timeout time.Duration) error {
+	return Eventually(func() error {
+		out, err := RunCommand(KubectlCommand, "get", "customresourcedefinition", name, "-o", "json")
+		if err != nil {
+			return err
+		}
+		var crd crdStatus
+		if err := json.Unmarshal([]byte(out), &crd); err != nil {
+			return fmt.Errorf("error parsing CustomResourceDefinition %s: %v", name, err)
+		}
+		for _, c := range crd.Status.Conditions {
+			if c.Type == "Established" && c.Status == "True" {
+				return nil
+			}
+		}
+		return fmt.Errorf("CustomResourceDefinition %s is not yet Established", name)
+	}, timeout, pollInterval)
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WorkspaceT is the subset of *testing.T (or ginkgo.GinkgoTInterface) that a
+// ProjectWorkspace needs in order to report progress and fail fast on setup
+// errors.
+type WorkspaceT interface {
+	Logf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// ProjectWorkspace is an isolated, per-spec GOPATH-shaped tempdir for
+// scaffolding and building a kubebuilder project. Creating one per Describe
+// via NewWorkspace, instead of mutating the shared TestContext.ProjectDir,
+// lets `ginkgo -p` run multiple Kubebuilder workflow scenarios in parallel
+// without them contaminating each other's state.
+type ProjectWorkspace struct {
+	// Dir is the workspace root, laid out like a GOPATH: Dir/src/<importPath>.
+	Dir string
+
+	t WorkspaceT
+}
+
+// NewWorkspace creates a fresh GOPATH-shaped tempdir and returns a
+// ProjectWorkspace rooted there. Callers should defer ws.Close(failed) so
+// the workspace is archived on failure and removed on success.
+func NewWorkspace(t WorkspaceT) *ProjectWorkspace {
+	dir, err := ioutil.TempDir("", "kubebuilder-e2e-")
+	if err != nil {
+		t.Fatalf("error creating workspace tempdir: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatalf("error creating workspace GOPATH layout: %v", err)
+	}
+	t.Logf("created workspace %s", dir)
+	return &ProjectWorkspace{Dir: dir, t: t}
+}
+
+// ProjectDir returns the directory scaffolding commands should run in for
+// the given project import path: Dir/src/<importPath>.
+func (w *ProjectWorkspace) ProjectDir(importPath string) string {
+	return filepath.Join(w.Dir, "src", importPath)
+}
+
+// NewCommand returns a *CommandBuilder for cmdType. A KubebuilderCommand is
+// bound to this workspace's project directory (created if missing),
+// replacing the global TestContext.ProjectDir that KubebuilderCmd otherwise
+// falls back to; other command types are returned unbound.
+func (w *ProjectWorkspace) NewCommand(importPath, cmdType string, args ...string) *CommandBuilder {
+	b := NewCommand(cmdType, args...)
+	if cmdType == KubebuilderCommand {
+		projectDir := w.ProjectDir(importPath)
+		if err := os.MkdirAll(projectDir, 0755); err != nil {
+			w.t.Fatalf("error creating project dir %s: %v", projectDir, err)
+		}
+		b.WithDir(projectDir)
+	}
+	return b
+}
+
+// Close finishes a workspace. On failure, it archives the whole workspace
+// plus `kubectl describe nodes`, `kubectl get events`, and the
+// controller-manager pod logs into TestContext.ArtifactDir for post-mortem
+// debugging; on success it just removes the workspace.
+func (w *ProjectWorkspace) Close(failed bool) {
+	if failed {
+		if err := w.collectArtifacts(); err != nil {
+			w.t.Logf("error collecting workspace artifacts: %v", err)
+		}
+	}
+	if err := os.RemoveAll(w.Dir); err != nil {
+		w.t.Logf("error removing workspace %s: %v", w.Dir, err)
+	}
+}
+
+func (w *ProjectWorkspace) collectArtifacts() error {
+	if TestContext.ArtifactDir == "" {
+		return nil
+	}
+
+	diagnostics := []struct {
+		name string
+		args []string
+	}{
+		{"nodes-describe.txt", []string{"describe", "nodes"}},
+		{"events.txt", []string{"get", "events", "--all-namespaces"}},
+		{"controller-manager-logs.txt", []string{"logs", "-l", "control-plane=controller-manager", "--all-containers", "--all-namespaces"}},
+	}
+	for _, d := range diagnostics {
+		out, err := RunCommand(KubectlCommand, d.args...)
+		if err != nil {
+			w.t.Logf("error collecting %s: %v", d.name, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(w.Dir, d.name), []byte(out), 0644); err != nil {
+			w.t.Logf("error writing %s: %v", d.name, err)
+		}
+	}
+
+	archivePath := filepath.Join(TestContext.ArtifactDir, fmt.Sprintf("workspace-%s.tar.gz", fileStamp()))
+	if err := tarDir(w.Dir, archivePath); err != nil {
+		return fmt.Errorf("error archiving workspace %s: %v", w.Dir, err)
+	}
+	w.t.Logf("archived workspace to %s", archivePath)
+	return nil
+}
+
+// tarDir writes a gzipped tar of srcDir's contents to destFile.
+func tarDir(srcDir, destFile string) error {
+	f, err := os.Create(destFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	defer gz.Close()
+
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+}